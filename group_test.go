@@ -0,0 +1,37 @@
+package persistentconn
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNestedGroupsInheritPrefixMethodsAndMiddleware(t *testing.T) {
+	rg := newHandlerRegistry()
+	services := rg.Group("services", http.MethodGet)
+	services.Use(markerMiddleware)
+	foo := services.Group("foo")
+	foo.register(":id", okHandler, nil)
+
+	req := Request{Method: http.MethodGet, Path: "services/foo/1"}
+	resp, err := rg.getHandler(req)(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if resp.Body != "1" {
+		t.Fatalf("expected path var id=1 to reach the handler, got body %q", resp.Body)
+	}
+	if resp.Headers["X-Middleware"] != "ran" {
+		t.Fatalf("expected parent group's middleware to apply to nested group route, headers: %v", resp.Headers)
+	}
+
+	disallowed, err := rg.getHandler(Request{Method: http.MethodPost, Path: "services/foo/1"})(Request{Method: http.MethodPost, Path: "services/foo/1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if disallowed.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected inherited defaultMethods to restrict to GET, got %d", disallowed.StatusCode)
+	}
+}
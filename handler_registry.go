@@ -19,60 +19,273 @@ func NoMatchingHandler(re Request) (Response, error) {
 	}, nil
 }
 
-// route represents a registered route that has a corresponding handler
+// methodNotAllowedHandler returns a handler reporting that the request's path
+// matched a registered route but the request's HTTP method did not, listing
+// the methods the route does accept in the Allow header per RFC 7231.
+func methodNotAllowedHandler(allowedMethods []string) Handler {
+	return func(re Request) (Response, error) {
+		return Response{
+			StatusCode: http.StatusMethodNotAllowed,
+			Headers:    map[string]string{"Allow": strings.Join(allowedMethods, ", ")},
+			Body:       "The requested path does not support this method.",
+		}, nil
+	}
+}
+
+// route represents a registered route that has a corresponding handler.
 type route struct {
-	Pattern *regexp.Regexp
 	Handler Handler
 	Methods []string
+	// Middlewares are this route's own middleware, e.g. passed to
+	// registerWithMiddleware; they don't include the owning group's.
+	Middlewares []Middleware
+	// Group is the registry this route was registered on (a Group, or the
+	// root registry itself), consulted live at dispatch time for its
+	// groupMiddlewares so that calling Use after registering a route on a
+	// group still applies to that route.
+	Group *handlerRegistry
 }
 
 // newRoute creates a new route object
-func newRoute(pathPattern string, handler Handler, allowedMethods []string) *route {
-	re := translatePatternToRegexp(pathPattern)
+func newRoute(handler Handler, allowedMethods []string, group *handlerRegistry, mw ...Middleware) *route {
 	return &route{
-		Pattern: re,
-		Handler: handler,
-		Methods: allowedMethods,
+		Handler:     handler,
+		Methods:     allowedMethods,
+		Middlewares: mw,
+		Group:       group,
 	}
 }
 
 // translatePatternToRegexp translates a path pattern in the format of "pc1/:<name>/pc2"
 // where "pc" stands for path component and can be any arbitary string, and ":name" will be replaced
 // based on the request's path. E.g. if request is hitting "pc1/hello/pc2", the param name=hello will
-// be stored in the context of the request
-func translatePatternToRegexp(pathPattern string) *regexp.Regexp {
+// be stored in the context of the request.
+//
+// The compiled pattern is anchored to the full path with "^...$" so that, e.g.
+// "users/:id" no longer also matches "users/1/extra" or "prefix-users/1". When
+// strictSlash is false (the default), a single optional trailing slash is
+// allowed regardless of whether pathPattern itself ends in one; when true, the
+// trailing slash must match pathPattern exactly.
+func translatePatternToRegexp(pathPattern string, strictSlash bool) *regexp.Regexp {
 	parts := strings.Split(pathPattern, "/")
 	regexpStrParts := make([]string, len(parts))
 	for idx, p := range parts {
 		if strings.HasPrefix(p, ":") {
-			p = fmt.Sprintf(`(?P<%s>[\S|^\/]+)`, p[1:])
+			p = fmt.Sprintf(`(?P<%s>[^/]+)`, p[1:])
 		}
 		regexpStrParts[idx] = p
 	}
 	regexpStr := strings.Join(regexpStrParts, "/")
-	re := regexp.MustCompile(regexpStr)
+	if !strictSlash {
+		regexpStr = strings.TrimSuffix(regexpStr, "/") + "/?"
+	}
+	re := regexp.MustCompile("^" + regexpStr + "$")
 	return re
 }
 
-// handlerRegistry is where all routes are stored
+// handlerRegistry is where all routes are stored. A handlerRegistry created
+// by Group shares its routes with, and dispatches through, the root registry
+// it was grouped from; see target.
 type handlerRegistry struct {
 	routes []*route
+	// strictSlash controls whether a registered route's trailing slash must
+	// be matched exactly. Defaults to false, i.e. trailing slashes are optional.
+	strictSlash bool
+	// middlewares wrap every matched Handler, in the order registered via Use.
+	// Only meaningful on a root registry (root == nil); see target.
+	middlewares []Middleware
+
+	// root is the registry that actually owns routes and the global
+	// middleware chain, or nil if this registry is itself the root.
+	root *handlerRegistry
+	// prefix is this group's URL prefix, already composed with its parent
+	// group's prefix, if any. Empty on a root registry.
+	prefix string
+	// defaultMethods are the allowed methods new routes get when register is
+	// called with a nil allowedMethods, inherited by nested groups unless
+	// overridden.
+	defaultMethods []string
+	// groupMiddlewares wrap every route registered on this exact group, read
+	// live by getHandler via each route's Group field (so Use can be called
+	// before or after registering routes on this group), in addition to any
+	// per-route middleware passed to registerWithMiddleware.
+	groupMiddlewares []Middleware
+
+	// index is the trie-based route lookup used by getHandler. Only
+	// meaningful on a root registry (root == nil); see target.
+	index *trieNode
+}
+
+// newHandlerRegistry creates a handlerRegistry with RecoveryMiddleware
+// installed as the outermost middleware, so a panic in any handler or
+// user-registered middleware can't take down the persistent-conn worker.
+func newHandlerRegistry() *handlerRegistry {
+	return &handlerRegistry{middlewares: []Middleware{RecoveryMiddleware}, index: &trieNode{}}
+}
+
+// addRoute appends rt to the target registry's flat route list (kept for
+// introspection) and indexes it in the route trie under fullPathPattern.
+func (rg *handlerRegistry) addRoute(fullPathPattern string, rt *route) {
+	t := rg.target()
+	if t.index == nil {
+		t.index = &trieNode{}
+	}
+	t.routes = append(t.routes, rt)
+	t.index.insert(fullPathPattern, t.strictSlash, rt)
+}
+
+// target returns the registry that routes should actually be appended to and
+// dispatched from: rg itself if it's a root registry, or the root it was
+// grouped from otherwise.
+func (rg *handlerRegistry) target() *handlerRegistry {
+	if rg.root != nil {
+		return rg.root
+	}
+	return rg
+}
+
+// Group returns a subrouter that registers routes under prefix, sharing this
+// registry's underlying route table and dispatch (prefix is joined onto each
+// child route's path pattern at registration time, before it is indexed in
+// the route trie, so getHandler's per-request lookup cost is unaffected). defaultMethods, if
+// given, become the allowed methods for routes registered on the group (and
+// its own nested groups) that don't specify their own; otherwise the parent's
+// defaultMethods are inherited. Use on the returned group adds middleware
+// shared by every route registered on it (read live at dispatch time, so
+// order relative to registering routes on the same group doesn't matter),
+// starting from a snapshot of this registry's own group middleware taken
+// when Group is called.
+func (rg *handlerRegistry) Group(prefix string, defaultMethods ...string) *handlerRegistry {
+	dm := rg.defaultMethods
+	if len(defaultMethods) > 0 {
+		dm = defaultMethods
+	}
+	return &handlerRegistry{
+		root:             rg.target(),
+		prefix:           joinPath(rg.prefix, prefix),
+		defaultMethods:   dm,
+		groupMiddlewares: append([]Middleware{}, rg.groupMiddlewares...),
+	}
+}
+
+// joinPath concatenates a group prefix and a child path into a single
+// "/"-separated path, without doubling or dropping slashes at the seam. A
+// leading slash on the joined path's first part and a trailing slash on its
+// last part are kept, since they are significant under StrictSlash.
+func joinPath(prefix, path string) string {
+	leading := strings.HasPrefix(prefix, "/") || (prefix == "" && strings.HasPrefix(path, "/"))
+	trailing := strings.HasSuffix(path, "/") || (path == "" && strings.HasSuffix(prefix, "/"))
+	prefix = strings.Trim(prefix, "/")
+	path = strings.Trim(path, "/")
+	var joined string
+	switch {
+	case prefix == "":
+		joined = path
+	case path == "":
+		joined = prefix
+	default:
+		joined = prefix + "/" + path
+	}
+	if leading {
+		joined = "/" + joined
+	}
+	if trailing && !strings.HasSuffix(joined, "/") {
+		joined += "/"
+	}
+	return joined
+}
+
+// StrictSlash sets whether routes registered on rg require an exact
+// trailing-slash match, and returns rg so it can be chained onto
+// newHandlerRegistry. It is a tree-wide setting shared by a single route
+// trie, so it must be called on the root registry, before any routes are
+// registered on it or on any of its groups; it panics if called on a Group,
+// since scoping it to a subtree would let two routes that land on the same
+// trie node disagree about how their shared path was split into segments.
+func (rg *handlerRegistry) StrictSlash(strict bool) *handlerRegistry {
+	if rg.root != nil {
+		panic("persistentconn: StrictSlash must be called on the root registry, not a Group")
+	}
+	rg.strictSlash = strict
+	return rg
+}
+
+// Use registers mw to wrap every Handler matched under this registry, in the
+// order given, with the first Middleware being the outermost. On a root
+// registry this extends the global chain, consulted live by getHandler. On
+// a group, mw is appended to groupMiddlewares, which getHandler also reads
+// live from the route's owning group, so Use may be called before or after
+// routes are registered on that same group. It is not retroactive across
+// group boundaries, though: a nested group only inherits its parent's
+// groupMiddlewares as of when Group was called.
+func (rg *handlerRegistry) Use(mw ...Middleware) {
+	if rg.root == nil {
+		rg.middlewares = append(rg.middlewares, mw...)
+		return
+	}
+	rg.groupMiddlewares = append(rg.groupMiddlewares, mw...)
 }
 
-// gethandler gets the handler based on the input reqeust's path info
+// gethandler gets the handler based on the input reqeust's path info, and
+// wraps it with the registry's global middleware chain exactly once. It
+// descends the registry's route trie one path component at a time rather
+// than running every registered route's regexp against req.Path. If the path
+// matches a registered pattern but req.Method isn't one of its allowed
+// methods, a 405 handler is returned with an Allow header listing the
+// methods that route does accept, rather than the generic 404 handler.
 func (rg *handlerRegistry) getHandler(req Request) Handler {
-	handler := NoMatchingHandler
-	for _, rt := range rg.routes {
-		if matches := rt.Pattern.FindStringSubmatch(req.Path); len(matches) > 0 && contains(rt.Methods, req.Method) {
-			// TODO: added matched paramter to request or context or whatever
-			return rt.Handler
+	return chain(rg.resolveHandler(req), rg.target().middlewares...)
+}
+
+// resolveHandler is getHandler's route-resolution step without the global
+// middleware chain: the matched route's handler, composed with its owning
+// group's middleware and its own route-scoped middleware. Internal callers
+// that need to re-dispatch a request that's already inside the global chain
+// (e.g. a rewrite route re-resolving its rewritten path) should call this
+// instead of getHandler, so the global chain isn't applied a second time.
+func (rg *handlerRegistry) resolveHandler(req Request) Handler {
+	t := rg.target()
+	if t.index == nil {
+		return NoMatchingHandler
+	}
+	leaf, vars := t.index.match(splitPath(req.Path, t.strictSlash))
+	if leaf == nil {
+		return NoMatchingHandler
+	}
+	var allowedMethods []string
+	for _, rt := range leaf.routes {
+		if !contains(rt.Methods, req.Method) {
+			allowedMethods = append(allowedMethods, rt.Methods...)
+			continue
+		}
+		var groupMw []Middleware
+		if rt.Group != nil {
+			groupMw = rt.Group.groupMiddlewares
+		}
+		handler := chain(rt.Handler, append(append([]Middleware{}, groupMw...), rt.Middlewares...)...)
+		return func(r Request) (Response, error) {
+			return handler(r.withVars(vars))
 		}
 	}
-	return handler
+	return methodNotAllowedHandler(allowedMethods)
 }
 
 // register func registers a path with a handler
 func (rg *handlerRegistry) register(path string, handler Handler, allowedMethods []string) {
-	route := newRoute(path, handler, allowedMethods)
-	rg.routes = append(rg.routes, route)
+	rg.registerWithMiddleware(path, handler, allowedMethods)
+}
+
+// registerWithMiddleware registers a path with a handler and route-scoped
+// middleware that only wraps this route, applied inside the registry's
+// global middleware chain. If rg is a group, path is joined onto the
+// group's prefix, a nil allowedMethods falls back to the group's
+// defaultMethods, and the group's own middleware (read live at dispatch
+// time, so Use may be called on rg before or after this) wraps the route
+// ahead of mw.
+func (rg *handlerRegistry) registerWithMiddleware(path string, handler Handler, allowedMethods []string, mw ...Middleware) {
+	if allowedMethods == nil {
+		allowedMethods = rg.defaultMethods
+	}
+	fullPath := joinPath(rg.prefix, path)
+	rg.addRoute(fullPath, newRoute(handler, allowedMethods, rg, mw...))
 }
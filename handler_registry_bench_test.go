@@ -0,0 +1,62 @@
+package persistentconn
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+func benchHandler(Request) (Response, error) {
+	return Response{StatusCode: 200}, nil
+}
+
+// buildBenchRegistry registers n distinct EAI-style endpoints, each with a
+// trailing wildcard, mirroring a module that registers one route per Splunk
+// EAI resource.
+func buildBenchRegistry(n int) *handlerRegistry {
+	rg := newHandlerRegistry()
+	for i := 0; i < n; i++ {
+		rg.register(fmt.Sprintf("services/resource%d/:id", i), benchHandler, []string{"GET"})
+	}
+	return rg
+}
+
+func BenchmarkGetHandler_Trie(b *testing.B) {
+	rg := buildBenchRegistry(200)
+	req := Request{Method: "GET", Path: "services/resource199/42"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rg.getHandler(req)
+	}
+}
+
+// linearRoute and linearScan reproduce the O(routes·regexp) lookup strategy
+// getHandler used before the trie index, kept only so this benchmark can
+// quantify the improvement against it.
+type linearRoute struct {
+	Pattern *regexp.Regexp
+	Methods []string
+}
+
+func linearScan(routes []linearRoute, path, method string) bool {
+	for _, rt := range routes {
+		if matches := rt.Pattern.FindStringSubmatch(path); len(matches) > 0 && contains(rt.Methods, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func BenchmarkGetHandler_LinearScan(b *testing.B) {
+	routes := make([]linearRoute, 200)
+	for i := range routes {
+		routes[i] = linearRoute{
+			Pattern: translatePatternToRegexp(fmt.Sprintf("services/resource%d/:id", i), false),
+			Methods: []string{"GET"},
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		linearScan(routes, "services/resource199/42", "GET")
+	}
+}
@@ -0,0 +1,89 @@
+package persistentconn
+
+import (
+	"net/http"
+	"testing"
+)
+
+func okHandler(req Request) (Response, error) {
+	return Response{StatusCode: http.StatusOK, Body: req.Var("id")}, nil
+}
+
+func TestTranslatePatternToRegexpParamStopsAtSlash(t *testing.T) {
+	re := translatePatternToRegexp("users/:id", false)
+
+	if matches := re.FindStringSubmatch("users/1/extra"); matches != nil {
+		t.Fatalf("users/:id should not match users/1/extra, got submatches %v", matches)
+	}
+	if matches := re.FindStringSubmatch("prefix-users/1"); matches != nil {
+		t.Fatalf("users/:id should not match prefix-users/1, got submatches %v", matches)
+	}
+	matches := re.FindStringSubmatch("users/1")
+	if matches == nil {
+		t.Fatalf("users/:id should match users/1")
+	}
+	if got := matches[re.SubexpIndex("id")]; got != "1" {
+		t.Fatalf("expected id=1, got %q", got)
+	}
+}
+
+func TestGetHandlerMethodNotAllowed(t *testing.T) {
+	rg := newHandlerRegistry()
+	rg.register("users/:id", okHandler, []string{http.MethodGet, http.MethodPut})
+
+	handler := rg.getHandler(Request{Method: http.MethodPost, Path: "users/1"})
+	resp, err := handler(Request{Method: http.MethodPost, Path: "users/1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", resp.StatusCode)
+	}
+	if allow := resp.Headers["Allow"]; allow != "GET, PUT" {
+		t.Fatalf("expected Allow header %q, got %q", "GET, PUT", allow)
+	}
+}
+
+func TestStrictSlashPanicsOnGroup(t *testing.T) {
+	rg := newHandlerRegistry()
+	grp := rg.Group("bar")
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected StrictSlash on a group to panic")
+		}
+	}()
+	grp.StrictSlash(true)
+}
+
+func TestGroupUseAppliesToRoutesRegisteredBeforeIt(t *testing.T) {
+	rg := newHandlerRegistry()
+	grp := rg.Group("api")
+	grp.register("first", okHandler, []string{http.MethodGet})
+	grp.Use(markerMiddleware)
+	grp.register("second", okHandler, []string{http.MethodGet})
+
+	for _, path := range []string{"api/first", "api/second"} {
+		resp, err := rg.getHandler(Request{Method: http.MethodGet, Path: path})(Request{Method: http.MethodGet, Path: path})
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", path, err)
+		}
+		if resp.Headers["X-Middleware"] != "ran" {
+			t.Fatalf("expected group middleware to run for %s, headers: %v", path, resp.Headers)
+		}
+	}
+}
+
+func TestGetHandlerNoMatch(t *testing.T) {
+	rg := newHandlerRegistry()
+	rg.register("users/:id", okHandler, []string{http.MethodGet})
+
+	handler := rg.getHandler(Request{Method: http.MethodGet, Path: "accounts/1"})
+	resp, err := handler(Request{Method: http.MethodGet, Path: "accounts/1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+}
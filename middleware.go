@@ -0,0 +1,37 @@
+package persistentconn
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Middleware wraps a Handler with cross-cutting behavior (logging, auth,
+// request-id, metrics, recovery, etc.) and returns the wrapped Handler.
+type Middleware func(Handler) Handler
+
+// chain composes mw in the order given such that the first Middleware is the
+// outermost, i.e. chain(h, a, b) behaves as a(b(h)).
+func chain(handler Handler, mw ...Middleware) Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		handler = mw[i](handler)
+	}
+	return handler
+}
+
+// RecoveryMiddleware recovers from a panic in the wrapped Handler and turns
+// it into a 500 Response, so that a buggy handler cannot take down the
+// persistent-conn worker.
+func RecoveryMiddleware(next Handler) Handler {
+	return func(req Request) (resp Response, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				resp = Response{
+					StatusCode: http.StatusInternalServerError,
+					Body:       fmt.Sprintf("panic recovered: %v", r),
+				}
+				err = nil
+			}
+		}()
+		return next(req)
+	}
+}
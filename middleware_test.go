@@ -0,0 +1,23 @@
+package persistentconn
+
+import (
+	"net/http"
+	"testing"
+)
+
+func panickingHandler(Request) (Response, error) {
+	panic("boom")
+}
+
+func TestRecoveryMiddlewareConvertsPanicToInternalServerError(t *testing.T) {
+	rg := newHandlerRegistry()
+	rg.register("boom", panickingHandler, []string{http.MethodGet})
+
+	resp, err := rg.getHandler(Request{Method: http.MethodGet, Path: "boom"})(Request{Method: http.MethodGet, Path: "boom"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,38 @@
+package persistentconn
+
+// Request represents an incoming persistent-conn request that gets dispatched
+// to a registered Handler.
+type Request struct {
+	Method  string
+	Path    string
+	Headers map[string]string
+	Body    string
+
+	vars map[string]string
+	// hops counts the number of rewrites this request has already gone
+	// through, so a rewrite loop can be detected and broken.
+	hops int
+}
+
+// Vars returns the path variables captured by the ":name" parameters of the
+// route pattern that matched this request, e.g. for a route registered as
+// "services/:name" and a request path of "services/foo", Vars()["name"] == "foo".
+// It returns an empty, non-nil map if no variables were captured.
+func (r Request) Vars() map[string]string {
+	if r.vars == nil {
+		return map[string]string{}
+	}
+	return r.vars
+}
+
+// Var returns the captured path variable with the given name, or "" if it
+// wasn't captured by the matched route.
+func (r Request) Var(name string) string {
+	return r.Vars()[name]
+}
+
+// withVars returns a copy of r with its captured path variables set to vars.
+func (r Request) withVars(vars map[string]string) Request {
+	r.vars = vars
+	return r
+}
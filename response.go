@@ -0,0 +1,8 @@
+package persistentconn
+
+// Response represents the result of handling a Request.
+type Response struct {
+	StatusCode int
+	Headers    map[string]string
+	Body       string
+}
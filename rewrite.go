@@ -0,0 +1,59 @@
+package persistentconn
+
+import "net/http"
+
+// maxRewriteHops bounds how many times a single request may be rewritten
+// before dispatch gives up, guarding against routes that rewrite into a
+// cycle.
+const maxRewriteHops = 10
+
+// RegisterRewrite registers a route that, when matched, rewrites req.Path
+// using regexp.ReplaceAllString semantics (replacement may reference the
+// matched pattern's capture groups as "$1" or "${name}", same as the named
+// groups translatePatternToRegexp produces for pathPattern) and re-dispatches
+// the rewritten request by re-resolving its route. This goes through
+// resolveHandler rather than getHandler: the rewrite route's own Handler is
+// already running inside the global middleware chain that getHandler applied
+// to reach it, so re-resolving through getHandler would wrap the destination
+// handler in that same chain a second time.
+func (rg *handlerRegistry) RegisterRewrite(pathPattern, replacement string, methods []string) {
+	fullPath := joinPath(rg.prefix, pathPattern)
+	fullReplacement := joinPath(rg.prefix, replacement)
+	pattern := translatePatternToRegexp(fullPath, rg.target().strictSlash)
+	rg.addRoute(fullPath, &route{
+		Methods: methods,
+		Group:   rg,
+		Handler: func(req Request) (Response, error) {
+			if req.hops >= maxRewriteHops {
+				return Response{
+					StatusCode: http.StatusLoopDetected,
+					Body:       "rewrite loop detected",
+				}, nil
+			}
+			req.Path = pattern.ReplaceAllString(req.Path, fullReplacement)
+			req.hops++
+			return rg.resolveHandler(req)(req)
+		},
+	})
+}
+
+// RegisterRedirect registers a route that, when matched, responds with an
+// HTTP redirect (statusCode should be http.StatusMovedPermanently or
+// http.StatusFound) whose Location header is derived from req.Path via the
+// same regexp.ReplaceAllString substitution as RegisterRewrite.
+func (rg *handlerRegistry) RegisterRedirect(pathPattern, replacement string, methods []string, statusCode int) {
+	fullPath := joinPath(rg.prefix, pathPattern)
+	fullReplacement := joinPath(rg.prefix, replacement)
+	pattern := translatePatternToRegexp(fullPath, rg.target().strictSlash)
+	rg.addRoute(fullPath, &route{
+		Methods: methods,
+		Group:   rg,
+		Handler: func(req Request) (Response, error) {
+			location := pattern.ReplaceAllString(req.Path, fullReplacement)
+			return Response{
+				StatusCode: statusCode,
+				Headers:    map[string]string{"Location": location},
+			}, nil
+		},
+	})
+}
@@ -0,0 +1,89 @@
+package persistentconn
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRegisterRedirectWithinGroupPrefixesReplacement(t *testing.T) {
+	rg := newHandlerRegistry()
+	grp := rg.Group("api")
+	grp.register("dest", okHandler, []string{http.MethodGet})
+	grp.RegisterRedirect("src", "dest", []string{http.MethodGet}, http.StatusFound)
+
+	resp, err := rg.getHandler(Request{Method: http.MethodGet, Path: "api/src"})(Request{Method: http.MethodGet, Path: "api/src"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected 302, got %d", resp.StatusCode)
+	}
+	if got := resp.Headers["Location"]; got != "api/dest" {
+		t.Fatalf("expected Location %q, got %q", "api/dest", got)
+	}
+}
+
+func markerMiddleware(next Handler) Handler {
+	return func(req Request) (Response, error) {
+		resp, err := next(req)
+		if err != nil {
+			return resp, err
+		}
+		if resp.Headers == nil {
+			resp.Headers = map[string]string{}
+		}
+		resp.Headers["X-Middleware"] = "ran"
+		return resp, nil
+	}
+}
+
+func TestRegisterRedirectRunsGroupMiddleware(t *testing.T) {
+	rg := newHandlerRegistry()
+	grp := rg.Group("api")
+	grp.Use(markerMiddleware)
+	grp.RegisterRedirect("src", "dest", []string{http.MethodGet}, http.StatusFound)
+
+	resp, err := rg.getHandler(Request{Method: http.MethodGet, Path: "api/src"})(Request{Method: http.MethodGet, Path: "api/src"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Headers["X-Middleware"] != "ran" {
+		t.Fatalf("expected group middleware to run for RegisterRedirect, headers: %v", resp.Headers)
+	}
+}
+
+func TestRegisterRewriteWithinGroupPrefixesReplacement(t *testing.T) {
+	rg := newHandlerRegistry()
+	grp := rg.Group("api")
+	grp.register("dest", okHandler, []string{http.MethodGet})
+	grp.RegisterRewrite("src", "dest", []string{http.MethodGet})
+
+	resp, err := rg.getHandler(Request{Method: http.MethodGet, Path: "api/src"})(Request{Method: http.MethodGet, Path: "api/src"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected rewrite to reach dest handler, got status %d", resp.StatusCode)
+	}
+}
+
+func TestGlobalMiddlewareRunsOnceAcrossRewriteHop(t *testing.T) {
+	rg := newHandlerRegistry()
+	calls := 0
+	rg.Use(func(next Handler) Handler {
+		return func(req Request) (Response, error) {
+			calls++
+			return next(req)
+		}
+	})
+	rg.register("dest", okHandler, []string{http.MethodGet})
+	rg.RegisterRewrite("src", "dest", []string{http.MethodGet})
+
+	_, err := rg.getHandler(Request{Method: http.MethodGet, Path: "src"})(Request{Method: http.MethodGet, Path: "src"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected global middleware to run exactly once across a rewrite hop, ran %d times", calls)
+	}
+}
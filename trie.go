@@ -0,0 +1,97 @@
+package persistentconn
+
+import "strings"
+
+// trieNode is one path component in the registry's route index. Descending
+// from the root by "/"-separated path components reaches, at most, one leaf
+// per request path: literal children are tried before the param child at
+// every level, so a literal segment always wins over a ":name" wildcard at
+// the same position, and a longer literal prefix wins transitively. This
+// replaces the O(routes·regexp) linear scan getHandler used to do with an
+// O(path length) descent.
+//
+// Param segments in this version of the router don't carry their own
+// per-segment constraint regexp (the route syntax only has plain ":name"
+// wildcards, not e.g. ":id([0-9]+)"), so a single param child per node is
+// enough; a constrained-param syntax would need the param child to be
+// resolved by trying each candidate's regexp in registration order instead
+// of being a single edge.
+type trieNode struct {
+	literal map[string]*trieNode
+	param   *trieNode
+	// paramName is the variable name captured by the param edge leading to
+	// this node. All routes that share this node (e.g. "users/:id/profile"
+	// and "users/:id/settings") are assumed to name the wildcard the same;
+	// the last registered name wins if they don't.
+	paramName string
+	// routes are the routes whose full pattern ends exactly at this node,
+	// in registration order.
+	routes []*route
+}
+
+// splitPath splits path into its "/"-separated components for trie descent.
+// When strictSlash is false, a leading/trailing slash is optional and
+// ignored; when true, it's a significant (empty) path component. This is
+// more lenient than translatePatternToRegexp, whose "/?" only makes a
+// trailing slash optional.
+func splitPath(path string, strictSlash bool) []string {
+	if !strictSlash {
+		path = strings.Trim(path, "/")
+		if path == "" {
+			return nil
+		}
+	}
+	return strings.Split(path, "/")
+}
+
+// insert adds rt to the trie under pathPattern, creating any intermediate
+// nodes needed.
+func (n *trieNode) insert(pathPattern string, strictSlash bool, rt *route) {
+	node := n
+	for _, seg := range splitPath(pathPattern, strictSlash) {
+		if strings.HasPrefix(seg, ":") {
+			if node.param == nil {
+				node.param = &trieNode{}
+			}
+			node.param.paramName = seg[1:]
+			node = node.param
+			continue
+		}
+		if node.literal == nil {
+			node.literal = map[string]*trieNode{}
+		}
+		child, ok := node.literal[seg]
+		if !ok {
+			child = &trieNode{}
+			node.literal[seg] = child
+		}
+		node = child
+	}
+	node.routes = append(node.routes, rt)
+}
+
+// match descends the trie following segments, preferring a literal child
+// over the param child at every level, and returns the leaf reached along
+// with the path variables captured by any param edges on the way there. It
+// returns a nil leaf if no registered pattern matches segments at all.
+func (n *trieNode) match(segments []string) (*trieNode, map[string]string) {
+	if len(segments) == 0 {
+		if len(n.routes) == 0 {
+			return nil, nil
+		}
+		return n, map[string]string{}
+	}
+	seg, rest := segments[0], segments[1:]
+	if child, ok := n.literal[seg]; ok {
+		if leaf, vars := child.match(rest); leaf != nil {
+			return leaf, vars
+		}
+	}
+	if n.param != nil {
+		if leaf, vars := n.param.match(rest); leaf != nil {
+			vars[n.param.paramName] = seg
+			return leaf, vars
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,11 @@
+package persistentconn
+
+// contains reports whether s is present in list.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}